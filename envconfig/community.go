@@ -0,0 +1,38 @@
+package envconfig
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// defaultMaxConcurrentDownloads is how many community model shards
+// downloadCommunityModels fans out at once when OLLAMA_MAX_CONCURRENT_DOWNLOADS
+// is unset.
+const defaultMaxConcurrentDownloads = 3
+
+// MaxConcurrentDownloads returns the maximum number of community model
+// shards to download concurrently, configured via
+// OLLAMA_MAX_CONCURRENT_DOWNLOADS. An unset or invalid value falls back to
+// defaultMaxConcurrentDownloads.
+func MaxConcurrentDownloads() int {
+	s := os.Getenv("OLLAMA_MAX_CONCURRENT_DOWNLOADS")
+	if s == "" {
+		return defaultMaxConcurrentDownloads
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		slog.Warn("invalid OLLAMA_MAX_CONCURRENT_DOWNLOADS, using default", "value", s, "default", defaultMaxConcurrentDownloads)
+		return defaultMaxConcurrentDownloads
+	}
+
+	return n
+}
+
+// GalleryIndexURL returns the default gallery index URL used by
+// GalleryApplier's ApplyHandler/ListHandler when a request doesn't specify
+// one, configured via OLLAMA_GALLERY_INDEX_URL. Returns "" when unset.
+func GalleryIndexURL() string {
+	return os.Getenv("OLLAMA_GALLERY_INDEX_URL")
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/envconfig"
+)
+
+// intermediateBlobs maps a digest to another blob already known to hold the
+// same content, e.g. a layer shared between two models pulled at different
+// times. It's a sync.Map, not a plain map behind a package-private mutex,
+// so that every access site — including any that predate the community
+// pull paths running several shards concurrently (see
+// downloadCommunityModels) — shares the same synchronization instead of
+// some call sites knowing about a mutex the others don't.
+var intermediateBlobs sync.Map
+
+// lookupIntermediateBlob is a concurrency-safe read of intermediateBlobs.
+func lookupIntermediateBlob(digest string) (string, bool) {
+	v, ok := intermediateBlobs.Load(digest)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+// evictIntermediateBlob is a concurrency-safe delete from intermediateBlobs.
+func evictIntermediateBlob(digest string) {
+	intermediateBlobs.Delete(digest)
+}
+
+// stagingDir returns blobs/staging, creating it if necessary. Downloads are
+// streamed directly into this directory and only promoted into the
+// content-addressed blob store once their digest is confirmed.
+func stagingDir() (string, error) {
+	dir, err := envconfig.Models()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "blobs", "staging")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// stagingFilePath returns the path a shard identified by key should be
+// streamed to while it downloads. key is stable across retries/restarts so
+// a partial download can be resumed instead of restarted.
+func stagingFilePath(key string) (string, error) {
+	dir, err := stagingDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, key+".part"), nil
+}
+
+// createModelBlob promotes a fully-downloaded, already-hashed staging file
+// into the content-addressed blob store under digest. The rename is atomic,
+// so a reader can never observe a partially-written blob at its final path.
+func createModelBlob(digest, stagingFile string) error {
+	if ib, ok := lookupIntermediateBlob(digest); ok {
+		p, err := GetBlobsPath(ib)
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			slog.Info("evicting intermediate blob which no longer exists", "digest", ib)
+			evictIntermediateBlob(digest)
+		} else if err != nil {
+			return err
+		} else {
+			return nil
+		}
+	}
+
+	path, err := GetBlobsPath(digest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		// already promoted by an earlier pull; nothing left to do.
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(stagingFile, path); err != nil {
+		return fmt.Errorf("failed to promote staged blob %s: %w", digest, err)
+	}
+
+	return nil
+}
+
+// stalePartialDownloadAge is how long a partial shard download sits in
+// blobs/staging before InitCommunityRegistry treats it as abandoned and
+// prunes it on startup, rather than leaving it to accumulate forever.
+const stalePartialDownloadAge = 7 * 24 * time.Hour
+
+// InitCommunityRegistry performs one-time startup housekeeping for the
+// community-registry pull and gallery-apply paths. It must be called once
+// from the daemon's real startup path (server.Serve), before any pulls
+// begin, so interrupted downloads from a previous run don't sit in
+// blobs/staging forever. NewGalleryApplier also calls it, since that's the
+// closest thing to a startup hook this package otherwise has, but the call
+// here is best-effort: a process that never constructs a GalleryApplier
+// still needs server.Serve to call InitCommunityRegistry directly.
+func InitCommunityRegistry() {
+	if err := PruneStagingBlobs(stalePartialDownloadAge); err != nil {
+		slog.Warn("failed to prune staging blobs", "error", err)
+	}
+}
+
+// PruneStagingBlobs removes staging files older than olderThan, cleaning up
+// after downloads that were interrupted long enough ago that they're
+// unlikely to ever be resumed. It's meant to be called once on daemon
+// startup, before any pulls begin.
+func PruneStagingBlobs(olderThan time.Duration) error {
+	dir, err := stagingDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().Before(cutoff) {
+			p := filepath.Join(dir, entry.Name())
+			if err := os.Remove(p); err != nil {
+				slog.Warn("failed to prune staging blob", "path", p, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
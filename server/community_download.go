@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+)
+
+// These are vars rather than consts so tests can shrink the retry/backoff
+// timing instead of waiting out the real schedule.
+var (
+	maxShardRetries = 5
+	initialBackoff  = 500 * time.Millisecond
+	maxBackoff      = 30 * time.Second
+)
+
+// downloadOpts describes a single file to fetch from a community registry.
+type downloadOpts struct {
+	provider CommunityRegistryProvider
+	repo     string
+	revision string
+	file     RemoteFile
+	regOpts  *registryOptions
+	fn       func(api.ProgressResponse)
+}
+
+// shardResult is the outcome of resolving a single community model file.
+// tmpFile is empty when the file was already present locally under its
+// known digest and didn't need to be downloaded at all.
+type shardResult struct {
+	file    RemoteFile
+	tmpFile string
+	digest  string
+}
+
+// downloadCommunityModels fans files out across a bounded worker pool and
+// downloads each one concurrently, in the style of Docker's xfer download
+// manager. The pool size is configurable via OLLAMA_MAX_CONCURRENT_DOWNLOADS.
+// Files whose sha256 is already known (from the registry's file-tree API)
+// and already exist locally under that digest are short-circuited: no
+// network request and no re-hash is performed for them.
+func downloadCommunityModels(ctx context.Context, provider CommunityRegistryProvider, repo, revision string, files []RemoteFile, regOpts *registryOptions, fn func(api.ProgressResponse)) ([]shardResult, error) {
+	workers := envconfig.MaxConcurrentDownloads()
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	results := make([]shardResult, len(files))
+	errs := make(chan error, len(files))
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i, file := range files {
+		i, file := i, file
+
+		if file.Sha256 != "" {
+			if p, err := GetBlobsPath(file.Sha256); err == nil {
+				if _, statErr := os.Stat(p); statErr == nil {
+					slog.Info("community file already cached, skipping download", "path", file.Path, "digest", file.Sha256)
+					results[i] = shardResult{file: file, digest: file.Sha256}
+					continue
+				}
+			}
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			tmpFile, digest, err := downloadCommunityModel(ctx, downloadOpts{
+				provider: provider,
+				repo:     repo,
+				revision: revision,
+				file:     file,
+				regOpts:  regOpts,
+				fn:       fn,
+			})
+			if err != nil {
+				errs <- fmt.Errorf("%s: %w", file.Path, err)
+				return
+			}
+
+			results[i] = shardResult{file: file, tmpFile: tmpFile, digest: digest}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	if err, ok := <-errs; ok {
+		return nil, err
+	}
+	return results, nil
+}
+
+// communityStagingKey derives a filesystem-safe, stable staging file name
+// for a file within repo@revision, e.g.
+// "huggingface.co_org_repo@main_model-00001-of-00002.gguf". Stability across
+// attempts (and process restarts) is what lets a partial download resume
+// instead of starting over.
+func communityStagingKey(registry, repo, revision, path string) string {
+	key := fmt.Sprintf("%s_%s@%s_%s", registry, repo, revision, path)
+	return strings.ReplaceAll(key, "/", "_")
+}
+
+// downloadCommunityModel streams a single file into the blobs/staging
+// directory, hashing it as it downloads, and retries failed attempts with
+// exponential backoff. On restart, a previous staging file is resumed via an
+// HTTP Range request instead of being re-downloaded from scratch. If the
+// file's expected digest is known ahead of time, the final digest is checked
+// against it. The returned path still lives under blobs/staging — callers
+// promote it into the blob store with createModelBlob.
+func downloadCommunityModel(ctx context.Context, opts downloadOpts) (string, string, error) {
+	partFile, err := stagingFilePath(communityStagingKey(opts.provider.Name(), opts.repo, opts.revision, opts.file.Path))
+	if err != nil {
+		return "", "", err
+	}
+	sumFile := partFile + ".sum"
+
+	var lastErr error
+	backoff := initialBackoff
+
+	for attempt := 0; attempt < maxShardRetries; attempt++ {
+		if attempt > 0 {
+			jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+			select {
+			case <-ctx.Done():
+				return "", "", ctx.Err()
+			case <-time.After(backoff + jitter):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		digest, err := downloadCommunityModelOnce(ctx, opts, partFile, sumFile)
+		if err == nil {
+			os.Remove(sumFile)
+			return partFile, digest, nil
+		}
+		lastErr = err
+	}
+
+	return "", "", fmt.Errorf("failed to download %s after %d attempts: %w", opts.file.Path, maxShardRetries, lastErr)
+}
+
+// downloadCommunityModelOnce performs a single download attempt, resuming
+// from partFile/sumFile if they already hold a partial, previously hashed
+// download.
+func downloadCommunityModelOnce(ctx context.Context, opts downloadOpts, partFile, sumFile string) (string, error) {
+	completed, err := fileSize(partFile)
+	if err != nil {
+		return "", err
+	}
+
+	url := opts.provider.ResolveBlobURL(opts.repo, opts.revision, opts.file.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if completed > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(completed, 10)+"-")
+	}
+	for k, v := range opts.provider.Auth(opts.regOpts) {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var h hash.Hash
+	flags := os.O_CREATE | os.O_WRONLY
+	if completed > 0 && resp.StatusCode == http.StatusPartialContent {
+		flags |= os.O_APPEND
+		h, err = loadHasherState(sumFile)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// the server ignored our Range request, or there's nothing to
+		// resume: start this file over from scratch.
+		flags |= os.O_TRUNC
+		completed = 0
+		h = sha256.New()
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("unexpected status downloading %s: %s", opts.file.Path, resp.Status)
+	}
+
+	f, err := os.OpenFile(partFile, flags, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	total := completed + resp.ContentLength
+
+	// Several shards download concurrently, so progress events need the
+	// file's digest to tell them apart; without it, callers watching fn see
+	// Total/Completed jump around with no way to attribute them to a shard.
+	fn := opts.fn
+	if fn != nil {
+		id := opts.file.Sha256
+		if id == "" {
+			id = opts.file.Path
+		}
+		fn = func(resp api.ProgressResponse) {
+			resp.Digest = id
+			opts.fn(resp)
+		}
+	}
+
+	digest, err := TransferringModelData(ctx, f, resp.Body, h, total, completed, func(h hash.Hash) error {
+		return saveHasherState(h, sumFile)
+	}, fn)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.file.Sha256 != "" && digest != opts.file.Sha256 {
+		return "", fmt.Errorf("digest mismatch for %s: expected %s, got %s", opts.file.Path, opts.file.Sha256, digest)
+	}
+
+	return digest, nil
+}
+
+func fileSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// fakeProvider serves file bytes straight from srv, the way a real
+// CommunityRegistryProvider would serve from huggingface.co or an OCI
+// registry, so downloadCommunityModel(s) can be exercised without a network
+// dependency.
+type fakeProvider struct {
+	srv *httptest.Server
+}
+
+func (p *fakeProvider) Name() string { return "fake.test" }
+
+func (p *fakeProvider) ResolveBlobURL(repo, revision, file string) string {
+	return p.srv.URL + "/" + repo + "/" + revision + "/" + file
+}
+
+func (p *fakeProvider) ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *fakeProvider) Auth(regOpts *registryOptions) http.Header { return nil }
+
+func setupStagingEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("OLLAMA_MODELS", t.TempDir())
+}
+
+func TestDownloadCommunityModelResumesViaRange(t *testing.T) {
+	setupStagingEnv(t)
+
+	content := strings.Repeat("community model shard bytes ", 1024)
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+
+	var serveFull atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if rng == "" || !serveFull.Load() {
+			// first attempt: drop the connection partway through so the
+			// second attempt has to resume instead of starting over.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(content[:len(content)/2]))
+			serveFull.Store(true)
+			return
+		}
+
+		var start int
+		fmt.Sscanf(rng, "bytes=%d-", &start)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, content[start:])
+	}))
+	defer srv.Close()
+
+	provider := &fakeProvider{srv: srv}
+	opts := downloadOpts{
+		provider: provider,
+		repo:     "org/repo",
+		revision: "main",
+		file:     RemoteFile{Path: "model.gguf", Size: int64(len(content)), Sha256: sum},
+	}
+
+	partFile, err := stagingFilePath(communityStagingKey(provider.Name(), opts.repo, opts.revision, opts.file.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumFile := partFile + ".sum"
+
+	if _, err := downloadCommunityModelOnce(context.Background(), opts, partFile, sumFile); err == nil {
+		t.Fatal("expected the truncated first attempt to fail")
+	}
+
+	digest, err := downloadCommunityModelOnce(context.Background(), opts, partFile, sumFile)
+	if err != nil {
+		t.Fatalf("resumed attempt failed: %v", err)
+	}
+	if digest != sum {
+		t.Fatalf("digest = %s, want %s", digest, sum)
+	}
+
+	got, err := os.ReadFile(partFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Fatalf("resumed file content mismatch: got %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestDownloadCommunityModelRetriesExhaustOnDigestMismatch(t *testing.T) {
+	setupStagingEnv(t)
+
+	origRetries, origInitial, origMax := maxShardRetries, initialBackoff, maxBackoff
+	maxShardRetries, initialBackoff, maxBackoff = 2, time.Millisecond, time.Millisecond
+	defer func() { maxShardRetries, initialBackoff, maxBackoff = origRetries, origInitial, origMax }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "wrong bytes entirely")
+	}))
+	defer srv.Close()
+
+	opts := downloadOpts{
+		provider: &fakeProvider{srv: srv},
+		repo:     "org/repo",
+		revision: "main",
+		file:     RemoteFile{Path: "model.gguf", Sha256: "sha256:" + strings.Repeat("a", 64)},
+	}
+
+	if _, _, err := downloadCommunityModel(context.Background(), opts); err == nil {
+		t.Fatal("expected digest mismatch to exhaust retries and return an error")
+	}
+}
+
+func TestDownloadCommunityModelsBoundsConcurrency(t *testing.T) {
+	setupStagingEnv(t)
+	t.Setenv("OLLAMA_MAX_CONCURRENT_DOWNLOADS", "2")
+
+	var inFlight, maxInFlight int64
+	var mu sync.Mutex
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+
+		mu.Lock()
+		if n > maxInFlight {
+			maxInFlight = n
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "x")
+	}))
+	defer srv.Close()
+
+	provider := &fakeProvider{srv: srv}
+	var files []RemoteFile
+	for i := 0; i < 6; i++ {
+		files = append(files, RemoteFile{Path: fmt.Sprintf("shard-%d.gguf", i), Sha256: ""})
+	}
+
+	_, err := downloadCommunityModels(context.Background(), provider, "org/repo", "main", files, nil, func(api.ProgressResponse) {})
+	if err != nil {
+		t.Fatalf("downloadCommunityModels failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxInFlight > 2 {
+		t.Fatalf("observed %d concurrent downloads, want at most 2", maxInFlight)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/envconfig"
+)
+
+// RemoteFile describes a single file living in a community repository, as
+// reported by the registry's file listing (tree API, OCI manifest, ...).
+type RemoteFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// manifestCacheTTL bounds how long a file listing for a mutable revision
+// (a branch like "main", which can gain or lose commits) is trusted before
+// fetchCommunityManifest refetches it. An immutable revision (a commit SHA)
+// never goes stale, so it isn't subject to this TTL.
+var manifestCacheTTL = 1 * time.Hour
+
+// commitSHARe matches a git commit SHA, full or abbreviated. Revisions that
+// don't match it are treated as mutable refs such as branch or tag names.
+var commitSHARe = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// isImmutableRevision reports whether revision pins an exact commit (and so
+// can never change out from under a cached listing) rather than a mutable
+// ref like "main" that can be force-pushed or simply advanced.
+func isImmutableRevision(revision string) bool {
+	return commitSHARe.MatchString(revision)
+}
+
+// manifestCacheEntry is the on-disk cache format for a file listing: the
+// listing itself plus when it was fetched, so a mutable revision's entry can
+// be aged out by manifestCacheTTL.
+type manifestCacheEntry struct {
+	Files     []RemoteFile `json:"files"`
+	CachedAt  time.Time    `json:"cached_at"`
+	Immutable bool         `json:"immutable"`
+}
+
+// fetchCommunityManifest returns the list of .gguf files (including split
+// shards such as model-00001-of-00005.gguf) available in repo@revision from
+// provider, so callers no longer need to hand-encode shard names into the
+// model tag. Results are cached on disk, keyed by provider/repo@revision, to
+// avoid refetching the listing on every pull. A cache entry for an immutable
+// revision (a commit SHA) is trusted forever; a mutable ref like "main" is
+// refetched once manifestCacheTTL has elapsed.
+func fetchCommunityManifest(ctx context.Context, provider CommunityRegistryProvider, repo, revision string) ([]RemoteFile, error) {
+	cachePath, err := communityManifestCachePath(provider.Name(), repo, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := os.ReadFile(cachePath); err == nil {
+		var cached manifestCacheEntry
+		if err := json.Unmarshal(b, &cached); err == nil {
+			if cached.Immutable || time.Since(cached.CachedAt) < manifestCacheTTL {
+				return cached.Files, nil
+			}
+		}
+	}
+
+	files, err := provider.ListFiles(ctx, repo, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := manifestCacheEntry{
+		Files:     files,
+		CachedAt:  time.Now(),
+		Immutable: isImmutableRevision(revision),
+	}
+	if b, err := json.Marshal(entry); err == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			_ = os.WriteFile(cachePath, b, 0o644)
+		}
+	}
+
+	return files, nil
+}
+
+// filterFilesByQuantization narrows files down to those whose path mentions
+// quantization (e.g. "Q4_0"), for repositories that host several
+// quantizations of the same model side by side. An empty quantization, or
+// one that doesn't match any file, returns files unchanged so callers never
+// end up pulling zero shards because of an unrecognized quant string.
+func filterFilesByQuantization(files []RemoteFile, quantization string) []RemoteFile {
+	if quantization == "" {
+		return files
+	}
+
+	filtered := make([]RemoteFile, 0, len(files))
+	for _, f := range files {
+		if strings.Contains(strings.ToUpper(f.Path), strings.ToUpper(quantization)) {
+			filtered = append(filtered, f)
+		}
+	}
+	if len(filtered) == 0 {
+		return files
+	}
+
+	return filtered
+}
+
+// communityManifestCachePath returns where the cached file listing for
+// registry/repository@revision is stored on disk.
+func communityManifestCachePath(registry, repository, revision string) (string, error) {
+	dir, err := envconfig.Models()
+	if err != nil {
+		return "", err
+	}
+
+	safeRepo := strings.ReplaceAll(repository, "/", "_")
+	return filepath.Join(dir, "blobs", "manifests-community", registry, fmt.Sprintf("%s@%s.json", safeRepo, revision)), nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// listFilesProvider is a minimal CommunityRegistryProvider that only needs
+// ListFiles to exercise fetchCommunityManifest's caching.
+type listFilesProvider struct {
+	calls int
+	files []RemoteFile
+}
+
+func (p *listFilesProvider) Name() string                                      { return "fake.test" }
+func (p *listFilesProvider) ResolveBlobURL(repo, revision, file string) string { return "" }
+func (p *listFilesProvider) Auth(regOpts *registryOptions) http.Header         { return nil }
+
+func (p *listFilesProvider) ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error) {
+	p.calls++
+	return p.files, nil
+}
+
+func TestFetchCommunityManifestCachesImmutableRevisionForever(t *testing.T) {
+	setupStagingEnv(t)
+
+	p := &listFilesProvider{files: []RemoteFile{{Path: "model.gguf", Sha256: "sha256:abc"}}}
+	sha := "1234567890abcdef1234567890abcdef12345678"
+
+	if _, err := fetchCommunityManifest(context.Background(), p, "org/repo", sha); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetchCommunityManifest(context.Background(), p, "org/repo", sha); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.calls != 1 {
+		t.Fatalf("ListFiles called %d times for an immutable revision, want 1", p.calls)
+	}
+}
+
+func TestFetchCommunityManifestRefetchesMutableRevisionAfterTTL(t *testing.T) {
+	setupStagingEnv(t)
+
+	p := &listFilesProvider{files: []RemoteFile{{Path: "model.gguf", Sha256: "sha256:abc"}}}
+
+	origTTL := manifestCacheTTL
+	manifestCacheTTL = 10 * time.Millisecond
+	defer func() { manifestCacheTTL = origTTL }()
+
+	if _, err := fetchCommunityManifest(context.Background(), p, "org/repo", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 1 {
+		t.Fatalf("ListFiles called %d times on first fetch, want 1", p.calls)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := fetchCommunityManifest(context.Background(), p, "org/repo", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 2 {
+		t.Fatalf("ListFiles called %d times after TTL expired, want 2", p.calls)
+	}
+}
+
+func TestFilterFilesByQuantization(t *testing.T) {
+	files := []RemoteFile{
+		{Path: "model.Q4_0.gguf"},
+		{Path: "model.Q5_K_M.gguf"},
+		{Path: "model.Q8_0.gguf"},
+	}
+
+	got := filterFilesByQuantization(files, "q4_0")
+	if len(got) != 1 || got[0].Path != "model.Q4_0.gguf" {
+		t.Fatalf("filterFilesByQuantization(q4_0) = %v, want only model.Q4_0.gguf", got)
+	}
+
+	if got := filterFilesByQuantization(files, ""); len(got) != len(files) {
+		t.Fatalf("empty quantization should return all files, got %d", len(got))
+	}
+
+	if got := filterFilesByQuantization(files, "Q9_unknown"); len(got) != len(files) {
+		t.Fatalf("unmatched quantization should fall back to all files, got %d", len(got))
+	}
+}
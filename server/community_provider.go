@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+const (
+	DefaultFactoryRegistry = "huggingface.co"
+	DefaultQuantify        = "Q4_0"
+	DefaultBranch          = "main"
+	DefaultFormat          = "gguf"
+)
+
+// CommunityRegistryProvider knows how to list and resolve files for a single
+// community model registry (e.g. huggingface.co, modelers.cn, or a private
+// OCI mirror). Built-in providers are registered in this package's init();
+// third parties can add their own with RegisterCommunityProvider.
+type CommunityRegistryProvider interface {
+	// Name is the registry hostname this provider serves, e.g. "huggingface.co".
+	Name() string
+
+	// ResolveBlobURL returns the URL to download a single file from repo at
+	// the given revision.
+	ResolveBlobURL(repo, revision, file string) string
+
+	// ListFiles returns the files available in repo at the given revision.
+	ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error)
+
+	// Auth returns any headers needed to authenticate requests made with
+	// regOpts, e.g. a bearer token.
+	Auth(regOpts *registryOptions) http.Header
+}
+
+var communityProviders struct {
+	mu sync.RWMutex
+	m  map[string]CommunityRegistryProvider
+}
+
+// RegisterCommunityProvider registers p under p.Name(), overwriting any
+// provider previously registered for that name.
+func RegisterCommunityProvider(p CommunityRegistryProvider) {
+	communityProviders.mu.Lock()
+	defer communityProviders.mu.Unlock()
+
+	if communityProviders.m == nil {
+		communityProviders.m = make(map[string]CommunityRegistryProvider)
+	}
+	communityProviders.m[p.Name()] = p
+}
+
+// getCommunityProvider looks up the provider registered for registry.
+func getCommunityProvider(registry string) (CommunityRegistryProvider, bool) {
+	communityProviders.mu.RLock()
+	defer communityProviders.mu.RUnlock()
+
+	p, ok := communityProviders.m[registry]
+	return p, ok
+}
+
+// IsSupportCommunityRegistry reports whether registry has a registered
+// CommunityRegistryProvider.
+func IsSupportCommunityRegistry(registry string) bool {
+	_, ok := getCommunityProvider(registry)
+	return ok
+}
+
+func init() {
+	RegisterCommunityProvider(&huggingFaceProvider{host: "huggingface.co"})
+	RegisterCommunityProvider(&modelersProvider{host: "modelers.cn"})
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// huggingFaceProvider resolves and lists files from huggingface.co-shaped
+// repositories using the raw/LFS resolve endpoint and the file-tree API.
+type huggingFaceProvider struct {
+	host string
+}
+
+func (p *huggingFaceProvider) Name() string { return p.host }
+
+func (p *huggingFaceProvider) ResolveBlobURL(repo, revision, file string) string {
+	return fmt.Sprintf(LfsModelResolverURL, p.host, repo, revision, file)
+}
+
+func (p *huggingFaceProvider) ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error) {
+	return fetchHFStyleTree(ctx, p.host, repo, revision)
+}
+
+func (p *huggingFaceProvider) Auth(regOpts *registryOptions) http.Header {
+	h := http.Header{}
+	if regOpts != nil && regOpts.Token != "" {
+		h.Set("Authorization", "Bearer "+regOpts.Token)
+	}
+	return h
+}
+
+// modelersProvider resolves and lists files from modelers.cn, which mirrors
+// huggingface.co's file-tree API shape but serves blobs from its own
+// resolve path.
+type modelersProvider struct {
+	host string
+}
+
+func (p *modelersProvider) Name() string { return p.host }
+
+func (p *modelersProvider) ResolveBlobURL(repo, revision, file string) string {
+	return fmt.Sprintf("https://%s/coderepo/web/v1/file/%s/%s/media/%s", p.host, repo, revision, file)
+}
+
+func (p *modelersProvider) ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error) {
+	return fetchHFStyleTree(ctx, p.host, repo, revision)
+}
+
+func (p *modelersProvider) Auth(regOpts *registryOptions) http.Header {
+	h := http.Header{}
+	if regOpts != nil && regOpts.Token != "" {
+		h.Set("Authorization", "Bearer "+regOpts.Token)
+	}
+	return h
+}
+
+// hfTreeEntry is the shape returned by the HuggingFace-style tree API: one
+// entry per file or directory in the repository.
+type hfTreeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		Oid string `json:"oid"`
+	} `json:"lfs"`
+}
+
+// fetchHFStyleTree fetches the file tree for repo@revision from a registry
+// that implements the HuggingFace tree API shape.
+func fetchHFStyleTree(ctx context.Context, host, repo, revision string) ([]RemoteFile, error) {
+	url := fmt.Sprintf(JsonModelsFileTreeURL, host, repo, revision, "")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list files for %s@%s: %s", repo, revision, resp.Status)
+	}
+
+	var entries []hfTreeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	var files []RemoteFile
+	for _, e := range entries {
+		if e.Type != "file" || !strings.HasSuffix(e.Path, "."+DefaultFormat) {
+			continue
+		}
+
+		rf := RemoteFile{Path: e.Path, Size: e.Size}
+		if e.LFS != nil && e.LFS.Oid != "" {
+			rf.Sha256 = "sha256:" + e.LFS.Oid
+		}
+		files = append(files, rf)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ociModelMediaType is the media type used for GGUF models packaged and
+// distributed as OCI artifact blobs (e.g. by hauler/ocil-produced mirrors).
+const ociModelMediaType = "application/vnd.ollama.image.model"
+
+// ociProvider pulls GGUF artifacts from a generic OCI Distribution v2
+// registry, rather than a HuggingFace-shaped repo host. One provider
+// instance serves a single registry host.
+type ociProvider struct {
+	host string
+}
+
+// NewOCIProvider returns a CommunityRegistryProvider backed by the OCI
+// Distribution API v2 on host, for registries that serve GGUF models as OCI
+// artifact blobs with media type ociModelMediaType.
+func NewOCIProvider(host string) CommunityRegistryProvider {
+	return &ociProvider{host: host}
+}
+
+func (p *ociProvider) Name() string { return p.host }
+
+func (p *ociProvider) ResolveBlobURL(repo, revision, file string) string {
+	// file is the blob digest (e.g. "sha256:abc123...") returned by ListFiles.
+	return fmt.Sprintf("https://%s/v2/%s/blobs/%s", p.host, repo, file)
+}
+
+func (p *ociProvider) ListFiles(ctx context.Context, repo, revision string) ([]RemoteFile, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", p.host, repo, revision)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OCI manifest for %s:%s: %s", repo, revision, resp.Status)
+	}
+
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+			Size      int64  `json:"size"`
+		} `json:"layers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	var files []RemoteFile
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ociModelMediaType {
+			continue
+		}
+
+		// Path is the blob digest itself: OCI blobs are content-addressed,
+		// so ResolveBlobURL needs nothing more to build the download URL.
+		files = append(files, RemoteFile{Path: layer.Digest, Size: layer.Size, Sha256: layer.Digest})
+	}
+
+	return files, nil
+}
+
+func (p *ociProvider) Auth(regOpts *registryOptions) http.Header {
+	h := http.Header{}
+	if regOpts != nil && regOpts.Token != "" {
+		h.Set("Authorization", "Bearer "+regOpts.Token)
+	}
+	return h
+}
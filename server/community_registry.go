@@ -10,7 +10,6 @@ import (
 	"log/slog"
 	"os"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/ollama/ollama/api"
@@ -20,19 +19,6 @@ import (
 	"github.com/ollama/ollama/types/model"
 )
 
-const (
-	DefaultFactoryRegistry = "huggingface.co"
-	DefaultQuantify        = "Q4_0"
-	DefaultBranch          = "main"
-	DefaultFormat          = "gguf"
-)
-
-var (
-	// Used to validate if the registry is supportable
-	SupportCommunityRegistry        = []string{"huggingface.co", "modelers.cn"}
-	SupportCommunityRegistrySubPath = []string{"%s/resolve/main/%s", "/coderepo/web/v1/file/%s/main/media/%s"}
-)
-
 const (
 	AgreementModelURL      = "https://%s/%s"
 	AgreementDatasetURL    = "https://%s/datasets/%s"
@@ -58,144 +44,73 @@ type CommunityModel struct {
 	transformFn TransformFn
 }
 
-func IsSupportCommunityRegistry(registry string) bool {
-	for _, item := range SupportCommunityRegistry {
-		if registry == item {
-			return true
-		}
-	}
-	return false
+func PullModelFromCommunityRegistry(ctx context.Context, mp ModelPath, regOpts *registryOptions, fn func(api.ProgressResponse)) error {
+	return pullModelFromCommunityRegistry(ctx, mp, "", regOpts, fn)
 }
 
-func GetSupportCommunityRegistrySubPath(registry string) string {
-	for i, item := range SupportCommunityRegistry {
-		if registry == item {
-			return SupportCommunityRegistrySubPath[i]
-		}
-	}
-	return ""
+// PullQuantizedModelFromCommunityRegistry is like PullModelFromCommunityRegistry,
+// but narrows the pull to shards whose filename matches quantization (e.g.
+// "Q4_0"), for repositories that host several quantizations of the same
+// model side by side. An empty quantization pulls every available shard,
+// same as PullModelFromCommunityRegistry.
+func PullQuantizedModelFromCommunityRegistry(ctx context.Context, mp ModelPath, quantization string, regOpts *registryOptions, fn func(api.ProgressResponse)) error {
+	return pullModelFromCommunityRegistry(ctx, mp, quantization, regOpts, fn)
 }
 
-func createModelBlob(digest string, file string) error {
-	bin, err := os.Open(file)
-	if err != nil {
-		return err
+func pullModelFromCommunityRegistry(ctx context.Context, mp ModelPath, quantization string, regOpts *registryOptions, fn func(api.ProgressResponse)) error {
+	provider, ok := getCommunityProvider(mp.Registry)
+	if !ok {
+		return fmt.Errorf("unsupported community registry %q", mp.Registry)
 	}
-	defer bin.Close()
-
-	if ib, ok := intermediateBlobs[digest]; ok {
-		p, err := GetBlobsPath(ib)
-		if err != nil {
-			return err
-		}
 
-		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
-			slog.Info("evicting intermediate blob which no longer exists", "digest", ib)
-			delete(intermediateBlobs, digest)
-		} else if err != nil {
-			return err
-		} else {
-			return nil
-		}
+	revision := mp.Tag
+	if revision == "" {
+		revision = DefaultBranch
 	}
 
-	path, err := GetBlobsPath(digest)
+	files, err := fetchCommunityManifest(ctx, provider, mp.Repository, revision)
 	if err != nil {
 		return err
 	}
-
-	_, err = os.Stat(path)
-	switch {
-	case errors.Is(err, os.ErrNotExist):
-		// noop
-	case err != nil:
-		return err
-	default:
-		return nil
+	files = filterFilesByQuantization(files, quantization)
+	if len(files) == 0 {
+		return fmt.Errorf("no %s files found in %s@%s", DefaultFormat, mp.Repository, revision)
 	}
 
-	layer, err := NewLayer(bin, "")
+	results, err := downloadCommunityModels(ctx, provider, mp.Repository, revision, files, regOpts, fn)
 	if err != nil {
-		return nil
-	}
-
-	if layer.Digest != digest {
-		slog.Info("digest mismatch, expected %q, got %q", digest, layer.Digest)
-		return fmt.Errorf("digest mismatch, expected %q, got %q", digest, layer.Digest)
-	}
-	return nil
-}
-
-func PullModelFromCommunityRegistry(ctx context.Context, mp ModelPath, regOpts *registryOptions, fn func(api.ProgressResponse)) error {
-	slog.Info(fmt.Sprintf("yyyyyyyyyyyyyyyyyyyyy mp.Tag %s", mp.Tag))
-	mp.subPath = GetSupportCommunityRegistrySubPath(mp.Registry)
-	if mp.subPath == "" {
-		return fmt.Errorf("Failed to get community registry subPath")
-	}
-
-	count := strings.Count(mp.Tag, ":")
-	if count > 2 {
-		return fmt.Errorf("Invalid model name")
+		return err
 	}
 
-	slog.Info(fmt.Sprintf("yyyyyyyyyyyyyyyyyyyyy count %d", count))
-
-	var modelFiles []string
-	var digests []string
-	modelFiles = strings.Split(mp.Tag, ":")
-
-	for _, modelFile := range modelFiles {
-		// 在这里处理每个 modelFile
-		mp.fileName = modelFile + ".gguf"
-
-		// download
-		// fn(api.ProgressResponse{Status: "pulling community model"})
-		tmpFile, err := downloadCommunityModel(ctx, downloadOpts{
-			mp:      mp,
-			digest:  "",
-			regOpts: regOpts,
-			fn:      fn,
-			name:    mp.fileName,
-		})
-		defer os.Remove(tmpFile)
-		if err != nil {
-			return err
+	digests := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.tmpFile == "" {
+			// already present locally under its known digest; nothing to
+			// promote into the blob store.
+			digests = append(digests, result.digest)
+			continue
 		}
 
-		// todo: cleanup?
-
-		slog.Info(fmt.Sprintf("yyyyyyyyyyyyyyyyyyyyy tmpFile is %s", tmpFile))
-
-		// transform
-
-		digest, err := TransferringModelData(tmpFile)
-		if err != nil {
-			return err
-		}
+		defer os.Remove(result.tmpFile)
 
-		err = createModelBlob(digest, tmpFile)
-		if err != nil {
+		if err := createModelBlob(result.digest, result.tmpFile); err != nil {
 			return err
 		}
-		digests = append(digests, digest)
+		digests = append(digests, result.digest)
 	}
 
 	name := model.ParseName(mp.Repository + ":" + mp.Tag)
 	if !name.IsValid() {
 		return fmt.Errorf("%s", errtypes.InvalidModelNameErrMsg)
 	}
-	slog.Info(fmt.Sprintf("yyyyyyyyyyyyyyyyyyyyy name is %s, digests: %s", name, digests))
 
 	if err := checkNameExists(name); err != nil {
 		return err
 	}
 	if err := CreateCommunityModel(ctx, name, digests, fn); err != nil {
-		slog.Info(fmt.Sprintf("yyyyyyyyyyyyyyyyyyyyy err:%s", err))
 		return err
 	}
 
-	// os.Remove(tmpFile)
-
 	return nil
 }
 
@@ -218,7 +133,7 @@ func CreateCommunityModel(ctx context.Context, name model.Name, digestArr []stri
 	var baseLayers []*layerGGML
 
 	for _, digest := range digestArr {
-		if ib, ok := intermediateBlobs[digest]; ok {
+		if ib, ok := lookupIntermediateBlob(digest); ok {
 			p, err := GetBlobsPath(ib)
 			if err != nil {
 				return err
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+	"github.com/ollama/ollama/types/model"
+)
+
+// GalleryModel is one entry in a community model gallery index: where to
+// pull the base model from, and any Modelfile-style overrides to layer on
+// top of it once CreateCommunityModel has written the base layers.
+type GalleryModel struct {
+	Name         string         `json:"name" yaml:"name"`
+	Gallery      string         `json:"gallery" yaml:"gallery"` // registry/repo:revision to pull
+	Quantization string         `json:"quantization,omitempty" yaml:"quantization,omitempty"`
+	License      string         `json:"license,omitempty" yaml:"license,omitempty"` // AgreementModelURL
+	System       string         `json:"system,omitempty" yaml:"system,omitempty"`
+	Template     string         `json:"template,omitempty" yaml:"template,omitempty"`
+	Parameters   map[string]any `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// GalleryJobStatus is the lifecycle state of a gallery apply job.
+type GalleryJobStatus string
+
+const (
+	GalleryJobPending GalleryJobStatus = "pending"
+	GalleryJobRunning GalleryJobStatus = "running"
+	GalleryJobSuccess GalleryJobStatus = "success"
+	GalleryJobError   GalleryJobStatus = "error"
+)
+
+// GalleryJob tracks the progress of one in-flight gallery apply. UUID is
+// immutable once the job is created, but Status, Error, and progress are all
+// written by the goroutine running the job while JobHandler reads them
+// concurrently from request goroutines, so all three are guarded by mu.
+type GalleryJob struct {
+	UUID string `json:"uuid"`
+
+	mu       sync.Mutex
+	status   GalleryJobStatus
+	errMsg   string
+	progress []api.ProgressResponse
+}
+
+func (j *GalleryJob) setStatus(status GalleryJobStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = status
+}
+
+func (j *GalleryJob) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = GalleryJobError
+	j.errMsg = err.Error()
+}
+
+func (j *GalleryJob) state() (GalleryJobStatus, string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.errMsg
+}
+
+func (j *GalleryJob) push(resp api.ProgressResponse) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress = append(j.progress, resp)
+}
+
+func (j *GalleryJob) snapshot() []api.ProgressResponse {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]api.ProgressResponse, len(j.progress))
+	copy(out, j.progress)
+	return out
+}
+
+// GalleryApplier runs "apply" jobs against a curated model gallery: pull the
+// base community model, layer on any Modelfile overrides the gallery entry
+// (or the caller) specifies, and write the resulting manifest. Jobs run
+// asynchronously; callers poll JobHandler with the UUID returned by
+// ApplyHandler to watch progress.
+type GalleryApplier struct {
+	mu   sync.RWMutex
+	jobs map[string]*GalleryJob
+}
+
+// NewGalleryApplier constructs a GalleryApplier. It calls
+// InitCommunityRegistry defensively so blobs/staging gets pruned even if a
+// caller builds a GalleryApplier without the daemon's startup path (e.g.
+// server.Serve) having called InitCommunityRegistry itself — see its doc
+// comment for why that's still the call site that matters.
+func NewGalleryApplier() *GalleryApplier {
+	InitCommunityRegistry()
+
+	return &GalleryApplier{jobs: make(map[string]*GalleryJob)}
+}
+
+// GalleryApplyRequest is the body of POST /api/gallery/apply.
+type GalleryApplyRequest struct {
+	Gallery   string         `json:"gallery"`
+	Name      string         `json:"name"`
+	Overrides map[string]any `json:"overrides"`
+}
+
+// ApplyHandler handles POST /api/gallery/apply: it looks up name in gallery's
+// index and queues a pull+transform+manifest-write job, responding with the
+// job's UUID immediately.
+func (g *GalleryApplier) ApplyHandler(c *gin.Context) {
+	var req GalleryApplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := fetchGalleryIndex(c.Request.Context(), req.Gallery)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, ok := findGalleryModel(entries, req.Name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model %q not found in gallery", req.Name)})
+		return
+	}
+
+	job := &GalleryJob{UUID: uuid.NewString(), status: GalleryJobPending}
+
+	g.mu.Lock()
+	g.jobs[job.UUID] = job
+	g.mu.Unlock()
+
+	go g.run(job, entry, req.Overrides)
+
+	c.JSON(http.StatusOK, gin.H{"uuid": job.UUID})
+}
+
+// JobHandler handles GET /api/gallery/jobs/:uuid, returning the job's
+// status and the api.ProgressResponse events recorded so far.
+func (g *GalleryApplier) JobHandler(c *gin.Context) {
+	id := c.Param("uuid")
+
+	g.mu.RLock()
+	job, ok := g.jobs[id]
+	g.mu.RUnlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("job %q not found", id)})
+		return
+	}
+
+	status, errMsg := job.state()
+	c.JSON(http.StatusOK, gin.H{
+		"uuid":     job.UUID,
+		"status":   status,
+		"error":    errMsg,
+		"progress": job.snapshot(),
+	})
+}
+
+// ListHandler handles GET /api/gallery/list?gallery=..., returning the
+// models available in the gallery index at gallery (or the configured
+// default index when gallery is empty).
+func (g *GalleryApplier) ListHandler(c *gin.Context) {
+	entries, err := fetchGalleryIndex(c.Request.Context(), c.Query("gallery"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"models": entries})
+}
+
+func (g *GalleryApplier) run(job *GalleryJob, entry GalleryModel, overrides map[string]any) {
+	job.setStatus(GalleryJobRunning)
+	fn := func(resp api.ProgressResponse) { job.push(resp) }
+
+	ctx := context.Background()
+
+	mp := ParseModelPath(entry.Gallery)
+	if err := PullQuantizedModelFromCommunityRegistry(ctx, mp, entry.Quantization, &registryOptions{}, fn); err != nil {
+		job.fail(err)
+		return
+	}
+
+	name := model.ParseName(mp.Repository + ":" + mp.Tag)
+	if err := applyGalleryOverrides(ctx, name, entry, overrides, fn); err != nil {
+		job.fail(err)
+		return
+	}
+
+	job.setStatus(GalleryJobSuccess)
+}
+
+// applyGalleryOverrides layers the gallery entry's system prompt, template,
+// and parameters (merged with any caller-supplied overrides) onto the
+// manifest CreateCommunityModel just wrote for name.
+func applyGalleryOverrides(ctx context.Context, name model.Name, entry GalleryModel, overrides map[string]any, fn func(api.ProgressResponse)) error {
+	manifest, err := ParseNamedManifest(name)
+	if err != nil {
+		return err
+	}
+
+	layers := manifest.Layers
+
+	parameters := make(map[string]any)
+	for k, v := range entry.Parameters {
+		parameters[k] = v
+	}
+	for k, v := range overrides {
+		parameters[k] = v
+	}
+
+	// Drop any system/template/params layers from a previous apply before
+	// appending the new ones, mirroring CreateCommunityModel's handling of
+	// inherited layers — otherwise re-applying a gallery entry accumulates a
+	// stack of stale layers instead of replacing them.
+	replacing := map[string]bool{
+		"application/vnd.ollama.image.system":   entry.System != "",
+		"application/vnd.ollama.image.template": entry.Template != "",
+		"application/vnd.ollama.image.params":   len(parameters) > 0,
+	}
+	layers = slices.DeleteFunc(layers, func(layer Layer) bool {
+		return replacing[layer.MediaType]
+	})
+
+	if entry.System != "" {
+		layer, err := NewLayer(strings.NewReader(entry.System), "application/vnd.ollama.image.system")
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	if entry.Template != "" {
+		layer, err := NewLayer(strings.NewReader(entry.Template), "application/vnd.ollama.image.template")
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	if len(parameters) > 0 {
+		b, err := json.Marshal(parameters)
+		if err != nil {
+			return err
+		}
+		layer, err := NewLayer(strings.NewReader(string(b)), "application/vnd.ollama.image.params")
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	fn(api.ProgressResponse{Status: "writing manifest"})
+	if err := WriteManifest(name, manifest.Config, layers); err != nil {
+		return err
+	}
+
+	fn(api.ProgressResponse{Status: "success"})
+	return nil
+}
+
+func findGalleryModel(entries []GalleryModel, name string) (GalleryModel, bool) {
+	for _, e := range entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return GalleryModel{}, false
+}
+
+// fetchGalleryIndex downloads and parses a gallery index (YAML or JSON) from
+// galleryURL, falling back to the index configured via
+// OLLAMA_GALLERY_INDEX_URL when galleryURL is empty.
+func fetchGalleryIndex(ctx context.Context, galleryURL string) ([]GalleryModel, error) {
+	if galleryURL == "" {
+		galleryURL = envconfig.GalleryIndexURL()
+	}
+	if galleryURL == "" {
+		return nil, fmt.Errorf("no gallery index configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, galleryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch gallery index %s: %s", galleryURL, resp.Status)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []GalleryModel
+	if strings.HasSuffix(galleryURL, ".json") {
+		err = json.Unmarshal(b, &entries)
+	} else {
+		err = yaml.Unmarshal(b, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gallery index %s: %w", galleryURL, err)
+	}
+
+	return entries, nil
+}
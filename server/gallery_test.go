@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+// TestGalleryJobConcurrentAccess exercises the race JobHandler and run() used
+// to hit: one goroutine driving the job's status/progress forward while
+// another reads it, as happens when a client polls JobHandler mid-run. Run
+// with -race to catch a regression.
+func TestGalleryJobConcurrentAccess(t *testing.T) {
+	job := &GalleryJob{UUID: "test", status: GalleryJobPending}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		job.setStatus(GalleryJobRunning)
+		for i := 0; i < 50; i++ {
+			job.push(api.ProgressResponse{Status: "working"})
+		}
+		job.fail(errors.New("boom"))
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			_, _ = job.state()
+			_ = job.snapshot()
+		}
+	}()
+
+	wg.Wait()
+
+	status, errMsg := job.state()
+	if status != GalleryJobError || errMsg != "boom" {
+		t.Fatalf("state() = (%v, %q), want (%v, %q)", status, errMsg, GalleryJobError, "boom")
+	}
+	if got := len(job.snapshot()); got != 50 {
+		t.Fatalf("snapshot() returned %d entries, want 50", got)
+	}
+}
@@ -3,12 +3,16 @@ package server
 import (
 	"context"
 	"crypto/sha256"
+	"encoding"
+	"encoding/gob"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
 	"os"
 	"time"
 
-	"github.com/ollama/ollama/progress"
+	"github.com/ollama/ollama/api"
 )
 
 // type CommunityTransform interface {
@@ -18,60 +22,140 @@ import (
 // TransformFn is transform for a type of model.
 type TransformFn func(ctx context.Context, mp ModelPath) (string, error)
 
-func TransferringModelData(path string) (string, error) {
-	p := progress.NewProgress(os.Stderr)
-	defer p.Stop()
+// hasherState is the on-disk representation of a partially-consumed sha256
+// hasher, so a shard download can resume hashing exactly where it left off
+// instead of re-reading bytes that were already streamed to disk.
+type hasherState struct {
+	Sum []byte
+}
 
-	status := "transferring model data"
-	spinner := progress.NewSpinner(status)
-	p.Add(status, spinner)
-	defer p.Stop()
+// loadHasherState restores a sha256 hasher from the sidecar file written by
+// saveHasherState, or returns a fresh hasher if no sidecar exists yet.
+func loadHasherState(path string) (hash.Hash, error) {
+	h := sha256.New()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var state hasherState
+	if err := gob.NewDecoder(f).Decode(&state); err != nil {
+		return nil, err
+	}
+
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.Sum); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
 
-	bin, err := os.Open(path)
+// saveHasherState checkpoints a sha256 hasher's internal state to path so a
+// later resumed download can pick up hashing without re-reading the bytes
+// already written to the partial file.
+func saveHasherState(h hash.Hash, path string) error {
+	sum, err := h.(encoding.BinaryMarshaler).MarshalBinary()
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer bin.Close()
-
-	// Get file info to retrieve the size
-	// fileInfo, err := bin.Stat()
-	// if err != nil {
-	// 	return "", err
-	// }
-	// fileSize := fileInfo.Size()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, bin); err != nil {
-		return "", err
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(hasherState{Sum: sum}); err != nil {
+		f.Close()
+		return err
 	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// checkpointInterval bounds how much hashed-but-uncheckpointed data a crash
+// can lose: small enough that a resumed download only re-verifies a small
+// tail, large enough that checkpointing isn't the bottleneck.
+const checkpointInterval = 1 * time.Second
 
-	if _, err := bin.Seek(0, io.SeekStart); err != nil {
-		return "", err
+// TransferringModelData copies src into dst while hashing it with sha256,
+// reporting progress through fn as it goes rather than re-reading the file
+// afterwards to compute a digest. completed is the number of bytes already
+// accounted for by h (e.g. from a resumed download), and total is the full
+// size of the shard being transferred. checkpoint, if non-nil, is invoked
+// periodically *and* on every exit path (including errors and context
+// cancellation) so the persisted hasher state never lags behind the bytes
+// actually written to dst.
+//
+// The copy runs entirely on the calling goroutine: h and the running byte
+// count are only ever touched from here, so there's nothing to synchronize.
+func TransferringModelData(ctx context.Context, dst io.Writer, src io.Reader, h hash.Hash, total, completed int64, checkpoint func(hash.Hash) error, fn func(api.ProgressResponse)) (string, error) {
+	status := "transferring model data"
+	if fn != nil {
+		fn(api.ProgressResponse{Status: status, Total: total, Completed: completed})
 	}
 
-	// var pw progressWriter
-	zeroStatus := "transferring model data 0%"
-	spinner.SetMessage(zeroStatus)
-
-	done := make(chan struct{})
-	defer close(done)
-
-	go func() {
-		ticker := time.NewTicker(60 * time.Millisecond)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				// spinner.SetMessage(fmt.Sprintf("transferring model data %d%%", int(100*pw.n.Load()/fileSize)))
-				spinner.SetMessage(fmt.Sprintf("transferring model data ..."))
-			case <-done:
-				spinner.SetMessage("transferring model data 100%")
-				return
+	mw := io.MultiWriter(dst, h)
+	buf := make([]byte, 32*1024)
+	lastCheckpoint := time.Now()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			checkpointOrLog(checkpoint, h)
+			return "", err
+		}
+
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			if _, werr := mw.Write(buf[:n]); werr != nil {
+				checkpointOrLog(checkpoint, h)
+				return "", werr
+			}
+			completed += int64(n)
+			if fn != nil {
+				fn(api.ProgressResponse{Status: status, Total: total, Completed: completed})
+			}
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			checkpointOrLog(checkpoint, h)
+			return "", rerr
+		}
+
+		if checkpoint != nil && time.Since(lastCheckpoint) >= checkpointInterval {
+			if err := checkpoint(h); err != nil {
+				return "", err
 			}
+			lastCheckpoint = time.Now()
 		}
-	}()
+	}
 
-	digest := fmt.Sprintf("sha256:%x", hash.Sum(nil))
+	if checkpoint != nil {
+		if err := checkpoint(h); err != nil {
+			return "", err
+		}
+	}
 
-	return digest, nil
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// checkpointOrLog best-effort persists the hasher state before an early
+// return; a failure here shouldn't mask the original error being returned.
+func checkpointOrLog(checkpoint func(hash.Hash) error, h hash.Hash) {
+	if checkpoint == nil {
+		return
+	}
+	if err := checkpoint(h); err != nil {
+		slog.Warn("failed to checkpoint hasher state", "error", err)
+	}
 }